@@ -0,0 +1,50 @@
+package datamapper
+
+import (
+	"context"
+	"reflect"
+)
+
+// BatchDataMapper is an optional extension of DataMapper for mappers that
+// can persist several entities of the same type in a single call, such as a
+// bulk INSERT. When a type's mapper implements it, Commit groups that
+// type's new, dirty, and deleted entities together and dispatches them in
+// one call instead of one call per entity. Dirty entities with a loaded
+// version or a ChangeSet are the exception: they are still dispatched one
+// at a time, since UpdateMany cannot carry that per-entity state.
+type BatchDataMapper interface {
+	DataMapper
+
+	// InsertMany persists several newly created entities at once
+	InsertMany(ctx context.Context, entities []Entity) error
+
+	// UpdateMany persists changes to several existing entities at once
+	UpdateMany(ctx context.Context, entities []Entity) error
+
+	// DeleteMany removes several entities from the datastore at once
+	DeleteMany(ctx context.Context, entities []Entity) error
+}
+
+// groupByType buckets the IDs in registry by the concrete type of the
+// entity each one maps to, so Commit can dispatch one batch per type
+func groupByType(registry map[string]Entity) map[reflect.Type][]string {
+	groups := make(map[reflect.Type][]string)
+
+	for id, entity := range registry {
+		typ := reflect.TypeOf(entity)
+		groups[typ] = append(groups[typ], id)
+	}
+
+	return groups
+}
+
+// entitiesForIDs looks up each of ids in registry, in order
+func entitiesForIDs(registry map[string]Entity, ids []string) []Entity {
+	entities := make([]Entity, 0, len(ids))
+
+	for _, id := range ids {
+		entities = append(entities, registry[id])
+	}
+
+	return entities
+}
@@ -0,0 +1,241 @@
+package datamapper
+
+import (
+	"context"
+	"testing"
+)
+
+type BatchMapperStub struct {
+	inserted [][]Entity
+	updated  [][]Entity
+	deleted  [][]Entity
+	err      error
+}
+
+func (m *BatchMapperStub) Insert(ctx context.Context, entity Entity) error {
+	return m.err
+}
+
+func (m *BatchMapperStub) Update(ctx context.Context, entity Entity) error {
+	return m.err
+}
+
+func (m *BatchMapperStub) Delete(ctx context.Context, entity Entity) error {
+	return m.err
+}
+
+func (m *BatchMapperStub) InsertMany(ctx context.Context, entities []Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.inserted = append(m.inserted, entities)
+
+	return nil
+}
+
+func (m *BatchMapperStub) UpdateMany(ctx context.Context, entities []Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.updated = append(m.updated, entities)
+
+	return nil
+}
+
+func (m *BatchMapperStub) DeleteMany(ctx context.Context, entities []Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.deleted = append(m.deleted, entities)
+
+	return nil
+}
+
+func TestRegisterNewCollectionRegistersEveryEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}
+
+	err := u.RegisterNewCollection(entities)
+	failOnUnexpectedErr(err, t)
+
+	if len(u.newObjects) != 2 {
+		t.Errorf("should register both entities as new, got %+v", u.newObjects)
+	}
+}
+
+func TestRegisterNewCollectionIsAllOrNothing(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: ""}}
+
+	err := u.RegisterNewCollection(entities)
+	if err == nil {
+		t.Fatal("should return an error when any entity in the collection is invalid")
+	}
+
+	if len(u.newObjects) != 0 {
+		t.Errorf("should not register any entity when one in the collection is invalid, got %+v", u.newObjects)
+	}
+}
+
+func TestRegisterNewCollectionIsAllOrNothingOnIntraSliceDuplicate(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: "1"}}
+
+	err := u.RegisterNewCollection(entities)
+	if err == nil {
+		t.Fatal("should return an error when the collection contains a duplicate ID")
+	}
+
+	if len(u.newObjects) != 0 {
+		t.Errorf("should not register any entity when the collection contains a duplicate ID, got %+v", u.newObjects)
+	}
+}
+
+func TestRegisterDirtyCollectionRegistersEveryEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}
+
+	err := u.RegisterDirtyCollection(entities)
+	failOnUnexpectedErr(err, t)
+
+	if len(u.dirtyObjects) != 2 {
+		t.Errorf("should register both entities as dirty, got %+v", u.dirtyObjects)
+	}
+}
+
+func TestRegisterDirtyCollectionIsAllOrNothingOnIntraSliceDuplicate(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{
+		&VersionedEntityStub{id: "1", version: 1},
+		&VersionedEntityStub{id: "1", version: 2},
+	}
+
+	err := u.RegisterDirtyCollection(entities)
+	if err == nil {
+		t.Fatal("should return an error when the collection contains a duplicate ID")
+	}
+
+	if len(u.dirtyObjects) != 0 {
+		t.Errorf("should not register any entity when the collection contains a duplicate ID, got %+v", u.dirtyObjects)
+	}
+
+	if len(u.loadedVersions) != 0 {
+		t.Errorf("should not record a loaded version for any entity when the collection contains a duplicate ID, got %+v", u.loadedVersions)
+	}
+}
+
+func TestRegisterDeletedCollectionRegistersEveryEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}
+
+	err := u.RegisterDeletedCollection(entities)
+	failOnUnexpectedErr(err, t)
+
+	if len(u.deletedObjects) != 2 {
+		t.Errorf("should register both entities as deleted, got %+v", u.deletedObjects)
+	}
+}
+
+func TestCommitBatchesEntitiesOfTheSameTypeIntoOneCall(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &BatchMapperStub{}
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	failOnUnexpectedErr(u.RegisterNewCollection([]Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}), t)
+	failOnUnexpectedErr(u.RegisterDirtyCollection([]Entity{&EntityStub{id: "3"}, &EntityStub{id: "4"}}), t)
+	failOnUnexpectedErr(u.RegisterDeletedCollection([]Entity{&EntityStub{id: "5"}, &EntityStub{id: "6"}}), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.inserted) != 1 || len(mapper.inserted[0]) != 2 {
+		t.Errorf("should insert both new entities in a single batch call, got %+v", mapper.inserted)
+	}
+
+	if len(mapper.updated) != 1 || len(mapper.updated[0]) != 2 {
+		t.Errorf("should update both dirty entities in a single batch call, got %+v", mapper.updated)
+	}
+
+	if len(mapper.deleted) != 1 || len(mapper.deleted[0]) != 2 {
+		t.Errorf("should delete both deleted entities in a single batch call, got %+v", mapper.deleted)
+	}
+}
+
+func TestCommitFallsBackToPerEntityDispatchWithoutBatchMapper(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &MapperStub{}
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	failOnUnexpectedErr(u.RegisterNewCollection([]Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.inserted) != 2 {
+		t.Errorf("should insert each entity individually, got %+v", mapper.inserted)
+	}
+}
+
+type VersionedBatchMapperStub struct {
+	individuallyUpdated []Entity
+	batchUpdated        [][]Entity
+}
+
+func (m *VersionedBatchMapperStub) Insert(ctx context.Context, entity Entity) error { return nil }
+func (m *VersionedBatchMapperStub) Delete(ctx context.Context, entity Entity) error { return nil }
+
+func (m *VersionedBatchMapperStub) Update(ctx context.Context, entity Entity) error {
+	m.individuallyUpdated = append(m.individuallyUpdated, entity)
+
+	return nil
+}
+
+func (m *VersionedBatchMapperStub) UpdateVersioned(ctx context.Context, entity Entity, expectedVersion int64) error {
+	m.individuallyUpdated = append(m.individuallyUpdated, entity)
+
+	return nil
+}
+
+func (m *VersionedBatchMapperStub) InsertMany(ctx context.Context, entities []Entity) error { return nil }
+func (m *VersionedBatchMapperStub) DeleteMany(ctx context.Context, entities []Entity) error { return nil }
+
+func (m *VersionedBatchMapperStub) UpdateMany(ctx context.Context, entities []Entity) error {
+	m.batchUpdated = append(m.batchUpdated, entities)
+
+	return nil
+}
+
+func TestCommitDispatchesVersionedDirtyEntitiesIndividuallyEvenWithBatchMapper(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &VersionedBatchMapperStub{}
+	u.RegisterMapper(&VersionedEntityStub{}, mapper)
+
+	first := &VersionedEntityStub{id: "1", version: 3}
+	second := &VersionedEntityStub{id: "2"}
+
+	failOnUnexpectedErr(u.RegisterDirty(first), t)
+	failOnUnexpectedErr(u.RegisterDirty(second), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.individuallyUpdated) != 2 {
+		t.Errorf("should dispatch every versioned entity individually so its loaded version is checked, got %+v", mapper.individuallyUpdated)
+	}
+
+	if len(mapper.batchUpdated) != 0 {
+		t.Errorf("should never fold versioned entities into a batch call, got %+v", mapper.batchUpdated)
+	}
+}
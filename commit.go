@@ -0,0 +1,430 @@
+package datamapper
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+// Commit dispatches every registered entity to its DataMapper, deleting
+// first, then inserting, then updating, removing each entity from its
+// registry as it is successfully dispatched. If any dispatch fails, Commit
+// stops immediately and returns the error; entities that were already
+// dispatched are not rolled back by Commit itself, so callers that need
+// atomicity should use CommitTx.
+//
+// A dirty entity whose mapper reports a ConflictError is left registered as
+// dirty instead of aborting the whole commit, so the caller can reload the
+// entity, re-register it, and retry; Commit still processes the remaining
+// dirty entities before returning the conflict.
+//
+// Tracked entities are promoted to the dirty set before OnBeforeCommit hooks
+// run, so a hook like OutboxCollector.Collect sees entities that were only
+// Track()ed and mutated, not just ones explicitly registered dirty.
+//
+// Hooks registered via OnBeforeCommit run first; if one fails, Commit stops
+// before dispatching anything. Hooks registered via OnAfterCommit run once
+// every entity has been dispatched, with a CommitResult describing what
+// happened. Hooks registered via OnRollback run whenever Commit aborts
+// outright (an OnBeforeCommit hook or a mapper returning a non-conflict
+// error) or whenever Rollback is called explicitly; they do not run for a
+// ConflictError, since that leaves the rest of the commit intact.
+func (unit *UnitOfWork) Commit(ctx context.Context) error {
+	result, err := unit.dispatch(ctx)
+
+	var conflictErr *ConflictError
+	if err != nil && !errors.As(err, &conflictErr) {
+		return err
+	}
+
+	for _, hook := range unit.afterCommitHooks {
+		hook(result)
+	}
+
+	return err
+}
+
+// dispatch runs every OnBeforeCommit hook and then dispatches every
+// registered entity to its mapper, same as Commit, but leaves firing
+// OnAfterCommit hooks to the caller. CommitTx uses this to defer
+// OnAfterCommit until the underlying transaction has actually committed,
+// instead of firing it the moment every mapper has been called.
+//
+// dispatch itself fires OnRollback hooks for any hard failure (an
+// OnBeforeCommit hook or a mapper returning a non-conflict error), since
+// that failure means the business transaction aborted regardless of who
+// called dispatch. It does not fire them for a ConflictError, which is
+// returned to the caller alongside whatever CommitResult was assembled
+// before the conflict was hit.
+func (unit *UnitOfWork) dispatch(ctx context.Context) (CommitResult, error) {
+	unit.promoteTrackedChanges()
+
+	for _, hook := range unit.beforeCommitHooks {
+		if err := hook(unit); err != nil {
+			err = errors.Annotate(err, "before-commit hook failed")
+			unit.fireRollbackHooks(err)
+
+			return CommitResult{}, err
+		}
+	}
+
+	var result CommitResult
+
+	deletedIDs, err := unit.commitDeleted(ctx)
+	if err != nil {
+		unit.fireRollbackHooks(err)
+
+		return result, errors.Trace(err)
+	}
+
+	result.DeletedIDs = deletedIDs
+
+	insertedIDs, err := unit.commitNew(ctx)
+	if err != nil {
+		unit.fireRollbackHooks(err)
+
+		return result, errors.Trace(err)
+	}
+
+	result.InsertedIDs = insertedIDs
+
+	updatedIDs, conflict := unit.commitDirty(ctx)
+	result.UpdatedIDs = updatedIDs
+
+	if conflict != nil {
+		var conflictErr *ConflictError
+		if !errors.As(conflict, &conflictErr) {
+			unit.fireRollbackHooks(conflict)
+
+			return result, errors.Trace(conflict)
+		}
+	}
+
+	return result, conflict
+}
+
+// commitDeleted dispatches every registry-of-the-same-type group of deleted
+// entities to BatchDataMapper.DeleteMany when the mapper supports it, or to
+// DataMapper.Delete one entity at a time otherwise, returning the IDs that
+// were successfully deleted
+func (unit *UnitOfWork) commitDeleted(ctx context.Context) ([]string, error) {
+	var deletedIDs []string
+
+	for typ, ids := range groupByType(unit.deletedObjects) {
+		m, ok := unit.mappers[typ]
+		if !ok {
+			return deletedIDs, errors.Errorf("no DataMapper registered for entity type %s", typ)
+		}
+
+		if bm, ok := m.(BatchDataMapper); ok {
+			entities := entitiesForIDs(unit.deletedObjects, ids)
+
+			if err := bm.DeleteMany(ctx, entities); err != nil {
+				return deletedIDs, errors.Annotatef(err, "failed to delete %d entities of type %s", len(entities), typ)
+			}
+
+			for _, id := range ids {
+				unit.fireDeleteHooks(unit.deletedObjects[id])
+				delete(unit.deletedObjects, id)
+			}
+
+			deletedIDs = append(deletedIDs, ids...)
+
+			continue
+		}
+
+		for _, id := range ids {
+			entity := unit.deletedObjects[id]
+
+			if err := m.Delete(ctx, entity); err != nil {
+				return deletedIDs, errors.Annotatef(err, "failed to delete entity with ID \"%s\"", id)
+			}
+
+			unit.fireDeleteHooks(entity)
+			delete(unit.deletedObjects, id)
+			deletedIDs = append(deletedIDs, id)
+		}
+	}
+
+	return deletedIDs, nil
+}
+
+// commitNew dispatches every registry-of-the-same-type group of new
+// entities to BatchDataMapper.InsertMany when the mapper supports it, or to
+// DataMapper.Insert one entity at a time otherwise, returning the IDs that
+// were successfully inserted
+func (unit *UnitOfWork) commitNew(ctx context.Context) ([]string, error) {
+	var insertedIDs []string
+
+	for typ, ids := range groupByType(unit.newObjects) {
+		m, ok := unit.mappers[typ]
+		if !ok {
+			return insertedIDs, errors.Errorf("no DataMapper registered for entity type %s", typ)
+		}
+
+		if bm, ok := m.(BatchDataMapper); ok {
+			entities := entitiesForIDs(unit.newObjects, ids)
+
+			if err := bm.InsertMany(ctx, entities); err != nil {
+				return insertedIDs, errors.Annotatef(err, "failed to insert %d entities of type %s", len(entities), typ)
+			}
+
+			for _, id := range ids {
+				unit.fireInsertHooks(unit.newObjects[id])
+				delete(unit.newObjects, id)
+			}
+
+			insertedIDs = append(insertedIDs, ids...)
+
+			continue
+		}
+
+		for _, id := range ids {
+			entity := unit.newObjects[id]
+
+			if err := m.Insert(ctx, entity); err != nil {
+				return insertedIDs, errors.Annotatef(err, "failed to insert entity with ID \"%s\"", id)
+			}
+
+			unit.fireInsertHooks(entity)
+			delete(unit.newObjects, id)
+			insertedIDs = append(insertedIDs, id)
+		}
+	}
+
+	return insertedIDs, nil
+}
+
+// commitDirty dispatches every registry-of-the-same-type group of dirty
+// entities to BatchDataMapper.UpdateMany when the mapper supports it and the
+// entity needs no per-entity handling (no loaded version to check, no
+// partial ChangeSet to apply), falling back to updateDirty one entity at a
+// time otherwise. It returns the IDs that were successfully updated; a
+// ConflictError from any entity is collected and returned once every dirty
+// entity has been attempted, leaving conflicted entities registered as
+// dirty so the caller can reload and retry.
+func (unit *UnitOfWork) commitDirty(ctx context.Context) ([]string, error) {
+	var updatedIDs []string
+	var conflict error
+
+	for typ, ids := range groupByType(unit.dirtyObjects) {
+		m, ok := unit.mappers[typ]
+		if !ok {
+			return updatedIDs, errors.Errorf("no DataMapper registered for entity type %s", typ)
+		}
+
+		batchable, individual := unit.partitionBatchable(m, ids)
+
+		if len(batchable) > 0 {
+			bm := m.(BatchDataMapper)
+			entities := entitiesForIDs(unit.dirtyObjects, batchable)
+
+			if err := bm.UpdateMany(ctx, entities); err != nil {
+				return updatedIDs, errors.Annotatef(err, "failed to update %d entities of type %s", len(entities), typ)
+			}
+
+			for _, id := range batchable {
+				entity := unit.dirtyObjects[id]
+
+				unit.fireUpdateHooks(entity)
+				unit.identityMap.retrack(entity)
+				delete(unit.dirtyObjects, id)
+			}
+
+			updatedIDs = append(updatedIDs, batchable...)
+		}
+
+		for _, id := range individual {
+			entity := unit.dirtyObjects[id]
+
+			if err := unit.updateDirty(ctx, m, id, entity); err != nil {
+				var conflictErr *ConflictError
+				if errors.As(err, &conflictErr) {
+					if conflict == nil {
+						conflict = conflictErr
+					}
+
+					continue
+				}
+
+				return updatedIDs, errors.Annotatef(err, "failed to update entity with ID \"%s\"", id)
+			}
+
+			unit.fireUpdateHooks(entity)
+			unit.identityMap.retrack(entity)
+			delete(unit.dirtyObjects, id)
+			delete(unit.loadedVersions, id)
+			delete(unit.changeSets, id)
+			updatedIDs = append(updatedIDs, id)
+		}
+	}
+
+	return updatedIDs, conflict
+}
+
+// partitionBatchable splits ids into the ones that can be folded into a
+// single BatchDataMapper.UpdateMany call and the ones that need individual
+// dispatch, either because m doesn't implement BatchDataMapper or because
+// the entity has per-entity state (a loaded version or a ChangeSet) that the
+// batch API has no way to express
+func (unit *UnitOfWork) partitionBatchable(m DataMapper, ids []string) (batchable, individual []string) {
+	if _, ok := m.(BatchDataMapper); !ok {
+		return nil, ids
+	}
+
+	for _, id := range ids {
+		_, hasLoadedVersion := unit.loadedVersions[id]
+		_, hasChanges := unit.changeSets[id]
+
+		if hasLoadedVersion || hasChanges {
+			individual = append(individual, id)
+		} else {
+			batchable = append(batchable, id)
+		}
+	}
+
+	return batchable, individual
+}
+
+// promoteTrackedChanges diffs every entity tracked by the identity map
+// against its snapshot and promotes any with changed exported fields to the
+// dirty set, recording the diff so the mapper can apply a partial update
+func (unit *UnitOfWork) promoteTrackedChanges() {
+	for entity, changes := range unit.identityMap.changedEntities() {
+		id := entity.GetID()
+
+		if _, isNew := unit.newObjects[id]; isNew {
+			continue
+		}
+
+		if _, isDeleted := unit.deletedObjects[id]; isDeleted {
+			continue
+		}
+
+		if _, isDirty := unit.dirtyObjects[id]; !isDirty {
+			unit.dirtyObjects[id] = entity
+		}
+
+		unit.changeSets[id] = changes
+
+		if versioned, ok := entity.(VersionedEntity); ok {
+			if _, hasLoadedVersion := unit.loadedVersions[id]; !hasLoadedVersion {
+				unit.loadedVersions[id] = versioned.GetVersion()
+			}
+		}
+	}
+}
+
+// updateDirty dispatches a single dirty entity to the mapper, preferring
+// VersionedDataMapper.UpdateVersioned when the entity has a known loaded
+// version, then PartialDataMapper.UpdateChanges when a ChangeSet was
+// computed for it, and falling back to a full Update otherwise
+func (unit *UnitOfWork) updateDirty(ctx context.Context, m DataMapper, id string, entity Entity) error {
+	expectedVersion, hasLoadedVersion := unit.loadedVersions[id]
+	if hasLoadedVersion {
+		if vm, ok := m.(VersionedDataMapper); ok {
+			return vm.UpdateVersioned(ctx, entity, expectedVersion)
+		}
+	}
+
+	if changes, hasChanges := unit.changeSets[id]; hasChanges {
+		if pm, ok := m.(PartialDataMapper); ok {
+			return pm.UpdateChanges(ctx, entity, changes)
+		}
+	}
+
+	return m.Update(ctx, entity)
+}
+
+// Rollback discards every registered entity without persisting it, leaving
+// registered mappers in place so the UnitOfWork can be reused for the next
+// business transaction, and then fires every OnRollback hook
+func (unit *UnitOfWork) Rollback() {
+	unit.newObjects = make(map[string]Entity)
+	unit.dirtyObjects = make(map[string]Entity)
+	unit.deletedObjects = make(map[string]Entity)
+	unit.loadedVersions = make(map[string]int64)
+	unit.changeSets = make(map[string]ChangeSet)
+
+	unit.fireRollbackHooks(errors.New("unit of work rolled back"))
+}
+
+// TxRunner wraps the begin/commit/rollback lifecycle of an underlying
+// transactional resource, such as a database/sql *Tx, so CommitTx can bind a
+// UnitOfWork's Commit to it. Implementations must make Commit and Rollback
+// safe to call without a matching Begin having succeeded.
+type TxRunner interface {
+	// Begin starts the underlying transaction
+	Begin(ctx context.Context) error
+
+	// Commit finalizes the underlying transaction
+	Commit() error
+
+	// Rollback discards the underlying transaction
+	Rollback() error
+}
+
+// CommitTx runs Commit within the business transaction managed by runner: it
+// begins the transaction, performs the usual Commit dispatch, and rolls the
+// transaction back if any mapper fails (including a ConflictError, since a
+// conflicted entity still means this business transaction cannot go ahead),
+// so a single entity's failure aborts every other write in the same
+// business transaction. OnAfterCommit hooks are deferred until runner.Commit
+// has actually succeeded, so they never fire for a transaction that the
+// underlying store rejected.
+//
+// dispatch still removes each entity from its registry and advances
+// loadedVersions/changeSets as it calls each mapper, before runner.Commit
+// runs; if runner.Commit then fails, those registries are not restored. A
+// caller that needs to retry after a CommitTx failure should re-register the
+// affected entities on a fresh UnitOfWork rather than reuse this one.
+func (unit *UnitOfWork) CommitTx(ctx context.Context, runner TxRunner) error {
+	if err := runner.Begin(ctx); err != nil {
+		return errors.Annotate(err, "failed to begin transaction")
+	}
+
+	result, err := unit.dispatch(ctx)
+	if err != nil {
+		if rollbackErr := runner.Rollback(); rollbackErr != nil {
+			return errors.Annotatef(err, "failed to roll back transaction after commit error: %s", rollbackErr)
+		}
+
+		return errors.Trace(err)
+	}
+
+	if err := runner.Commit(); err != nil {
+		err = errors.Annotate(err, "failed to commit transaction")
+		unit.fireRollbackHooks(err)
+
+		return err
+	}
+
+	for _, hook := range unit.afterCommitHooks {
+		hook(result)
+	}
+
+	return nil
+}
+
+// TxRunnerFunc adapts three begin/commit/rollback closures into a TxRunner,
+// for callers who don't have a *sql.Tx but can still bracket a transaction
+type TxRunnerFunc struct {
+	BeginFunc    func(ctx context.Context) error
+	CommitFunc   func() error
+	RollbackFunc func() error
+}
+
+// Begin starts the underlying transaction by calling BeginFunc
+func (f TxRunnerFunc) Begin(ctx context.Context) error {
+	return f.BeginFunc(ctx)
+}
+
+// Commit finalizes the underlying transaction by calling CommitFunc
+func (f TxRunnerFunc) Commit() error {
+	return f.CommitFunc()
+}
+
+// Rollback discards the underlying transaction by calling RollbackFunc
+func (f TxRunnerFunc) Rollback() error {
+	return f.RollbackFunc()
+}
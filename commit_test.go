@@ -0,0 +1,185 @@
+package datamapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCommitDispatchesToRegisteredMapper(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &MapperStub{}
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	newEntity := &EntityStub{id: "1"}
+	dirtyEntity := &EntityStub{id: "2"}
+	deletedEntity := &EntityStub{id: "3"}
+
+	failOnUnexpectedErr(u.RegisterNew(newEntity), t)
+	failOnUnexpectedErr(u.RegisterDirty(dirtyEntity), t)
+	failOnUnexpectedErr(u.RegisterDeleted(deletedEntity), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.inserted) != 1 || mapper.inserted[0] != newEntity {
+		t.Errorf("should have inserted the new entity, got %+v", mapper.inserted)
+	}
+
+	if len(mapper.updated) != 1 || mapper.updated[0] != dirtyEntity {
+		t.Errorf("should have updated the dirty entity, got %+v", mapper.updated)
+	}
+
+	if len(mapper.deleted) != 1 || mapper.deleted[0] != deletedEntity {
+		t.Errorf("should have deleted the deleted entity, got %+v", mapper.deleted)
+	}
+
+	if len(u.newObjects) != 0 || len(u.dirtyObjects) != 0 || len(u.deletedObjects) != 0 {
+		t.Error("should clear all registries after a successful commit")
+	}
+}
+
+func TestCommitFailsWithoutRegisteredMapper(t *testing.T) {
+	u := NewUnitOfWork()
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	err := u.Commit(context.Background())
+
+	if err == nil {
+		t.Error("should return an error when no mapper is registered for an entity's type")
+	}
+
+	errShouldContainStr(err, "no DataMapper registered", t)
+}
+
+func TestRollbackDiscardsAllRegistries(t *testing.T) {
+	u := NewUnitOfWork()
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+	failOnUnexpectedErr(u.RegisterDirty(&EntityStub{id: "2"}), t)
+	failOnUnexpectedErr(u.RegisterDeleted(&EntityStub{id: "3"}), t)
+
+	u.Rollback()
+
+	if len(u.newObjects) != 0 || len(u.dirtyObjects) != 0 || len(u.deletedObjects) != 0 {
+		t.Error("should discard every registered entity")
+	}
+}
+
+type TxRunnerStub struct {
+	began      bool
+	committed  bool
+	rolledBack bool
+	beginErr   error
+	commitErr  error
+}
+
+func (r *TxRunnerStub) Begin(ctx context.Context) error {
+	r.began = true
+
+	return r.beginErr
+}
+
+func (r *TxRunnerStub) Commit() error {
+	r.committed = true
+
+	return r.commitErr
+}
+
+func (r *TxRunnerStub) Rollback() error {
+	r.rolledBack = true
+
+	return nil
+}
+
+func TestCommitTxCommitsUnderlyingTransactionOnSuccess(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	runner := &TxRunnerStub{}
+
+	err := u.CommitTx(context.Background(), runner)
+	failOnUnexpectedErr(err, t)
+
+	if !runner.began || !runner.committed {
+		t.Error("should begin and commit the underlying transaction")
+	}
+
+	if runner.rolledBack {
+		t.Error("should not roll back the underlying transaction on success")
+	}
+}
+
+func TestCommitTxRollsBackUnderlyingTransactionOnMapperFailure(t *testing.T) {
+	u := NewUnitOfWork()
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	runner := &TxRunnerStub{}
+
+	err := u.CommitTx(context.Background(), runner)
+
+	if err == nil {
+		t.Error("should return an error when no mapper is registered for a dispatched entity")
+	}
+
+	if !runner.began {
+		t.Error("should have begun the underlying transaction")
+	}
+
+	if !runner.rolledBack {
+		t.Error("should roll back the underlying transaction when a mapper fails")
+	}
+}
+
+func TestCommitTxDoesNotFireAfterCommitHooksIfUnderlyingCommitFails(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	afterCommitFired := false
+	u.OnAfterCommit(func(r CommitResult) {
+		afterCommitFired = true
+	})
+
+	var rollbackErr error
+	u.OnRollback(func(err error) {
+		rollbackErr = err
+	})
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	runner := &TxRunnerStub{commitErr: errors.New("boom")}
+
+	err := u.CommitTx(context.Background(), runner)
+	if err == nil {
+		t.Fatal("should return an error when the underlying transaction fails to commit")
+	}
+
+	errShouldContainStr(err, "failed to commit transaction", t)
+
+	if afterCommitFired {
+		t.Error("should not fire OnAfterCommit hooks when the underlying transaction fails to commit")
+	}
+
+	if rollbackErr == nil {
+		t.Error("should fire OnRollback hooks when the underlying transaction fails to commit")
+	}
+}
+
+func TestCommitTxFailsIfBeginFails(t *testing.T) {
+	u := NewUnitOfWork()
+
+	runner := &TxRunnerStub{beginErr: errors.New("boom")}
+
+	err := u.CommitTx(context.Background(), runner)
+
+	if err == nil {
+		t.Error("should return an error when the underlying transaction fails to begin")
+	}
+
+	errShouldContainStr(err, "failed to begin transaction", t)
+}
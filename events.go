@@ -0,0 +1,87 @@
+package datamapper
+
+import "reflect"
+
+// CommitResult summarizes what a Commit persisted, reported to every
+// OnAfterCommit hook
+type CommitResult struct {
+	// InsertedIDs holds the IDs of every entity that was inserted
+	InsertedIDs []string
+
+	// UpdatedIDs holds the IDs of every entity that was updated
+	UpdatedIDs []string
+
+	// DeletedIDs holds the IDs of every entity that was deleted
+	DeletedIDs []string
+}
+
+// OnBeforeCommit registers a hook that runs before Commit dispatches
+// anything to a mapper. Returning an error from the hook aborts the commit
+// before any mapper is called, and fires the registered OnRollback hooks.
+func (unit *UnitOfWork) OnBeforeCommit(hook func(*UnitOfWork) error) {
+	unit.beforeCommitHooks = append(unit.beforeCommitHooks, hook)
+}
+
+// OnAfterCommit registers a hook that runs once Commit has dispatched every
+// registered entity. It also runs when the only failure was a ConflictError;
+// the CommitResult then leaves out the conflicted entities.
+func (unit *UnitOfWork) OnAfterCommit(hook func(CommitResult)) {
+	unit.afterCommitHooks = append(unit.afterCommitHooks, hook)
+}
+
+// OnRollback registers a hook that runs whenever Commit aborts, either
+// because an OnBeforeCommit hook failed or because a mapper returned an
+// error other than a ConflictError, and whenever Rollback is called
+// explicitly
+func (unit *UnitOfWork) OnRollback(hook func(error)) {
+	unit.rollbackHooks = append(unit.rollbackHooks, hook)
+}
+
+// OnInsert registers a hook that runs after an entity of sample's concrete
+// type is successfully inserted
+func (unit *UnitOfWork) OnInsert(sample Entity, hook func(Entity)) {
+	unit.insertHooks[reflect.TypeOf(sample)] = append(unit.insertHooks[reflect.TypeOf(sample)], hook)
+}
+
+// OnUpdate registers a hook that runs after an entity of sample's concrete
+// type is successfully updated
+func (unit *UnitOfWork) OnUpdate(sample Entity, hook func(Entity)) {
+	unit.updateHooks[reflect.TypeOf(sample)] = append(unit.updateHooks[reflect.TypeOf(sample)], hook)
+}
+
+// OnDelete registers a hook that runs after an entity of sample's concrete
+// type is successfully deleted
+func (unit *UnitOfWork) OnDelete(sample Entity, hook func(Entity)) {
+	unit.deleteHooks[reflect.TypeOf(sample)] = append(unit.deleteHooks[reflect.TypeOf(sample)], hook)
+}
+
+// fireInsertHooks runs every hook registered for entity's concrete type via
+// OnInsert
+func (unit *UnitOfWork) fireInsertHooks(entity Entity) {
+	for _, hook := range unit.insertHooks[reflect.TypeOf(entity)] {
+		hook(entity)
+	}
+}
+
+// fireUpdateHooks runs every hook registered for entity's concrete type via
+// OnUpdate
+func (unit *UnitOfWork) fireUpdateHooks(entity Entity) {
+	for _, hook := range unit.updateHooks[reflect.TypeOf(entity)] {
+		hook(entity)
+	}
+}
+
+// fireDeleteHooks runs every hook registered for entity's concrete type via
+// OnDelete
+func (unit *UnitOfWork) fireDeleteHooks(entity Entity) {
+	for _, hook := range unit.deleteHooks[reflect.TypeOf(entity)] {
+		hook(entity)
+	}
+}
+
+// fireRollbackHooks runs every hook registered via OnRollback
+func (unit *UnitOfWork) fireRollbackHooks(err error) {
+	for _, hook := range unit.rollbackHooks {
+		hook(err)
+	}
+}
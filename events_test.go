@@ -0,0 +1,148 @@
+package datamapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOnAfterCommitReceivesAccurateCommitResult(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	var result CommitResult
+	u.OnAfterCommit(func(r CommitResult) {
+		result = r
+	})
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+	failOnUnexpectedErr(u.RegisterDirty(&EntityStub{id: "2"}), t)
+	failOnUnexpectedErr(u.RegisterDeleted(&EntityStub{id: "3"}), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(result.InsertedIDs) != 1 || result.InsertedIDs[0] != "1" {
+		t.Errorf("should report the inserted ID, got %+v", result.InsertedIDs)
+	}
+
+	if len(result.UpdatedIDs) != 1 || result.UpdatedIDs[0] != "2" {
+		t.Errorf("should report the updated ID, got %+v", result.UpdatedIDs)
+	}
+
+	if len(result.DeletedIDs) != 1 || result.DeletedIDs[0] != "3" {
+		t.Errorf("should report the deleted ID, got %+v", result.DeletedIDs)
+	}
+}
+
+func TestOnBeforeCommitFailureAbortsCommitAndFiresRollbackHooks(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	u.OnBeforeCommit(func(unit *UnitOfWork) error {
+		return errors.New("before-commit check failed")
+	})
+
+	var rollbackErr error
+	u.OnRollback(func(err error) {
+		rollbackErr = err
+	})
+
+	afterCommitFired := false
+	u.OnAfterCommit(func(r CommitResult) {
+		afterCommitFired = true
+	})
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	err := u.Commit(context.Background())
+	if err == nil {
+		t.Fatal("should return the before-commit hook's error")
+	}
+
+	if rollbackErr == nil {
+		t.Error("should fire OnRollback hooks when a before-commit hook fails")
+	}
+
+	if afterCommitFired {
+		t.Error("should not fire OnAfterCommit hooks when the commit aborts")
+	}
+
+	if len(u.newObjects) != 1 {
+		t.Error("should leave the entity registered when the commit aborts before dispatch")
+	}
+}
+
+func TestOnRollbackNotFiredForConflictOnlyOutcome(t *testing.T) {
+	u := NewUnitOfWork()
+	mapper := &VersionedMapperStub{conflictFor: map[string]int64{"1": 2}}
+	u.RegisterMapper(&VersionedEntityStub{}, mapper)
+
+	rollbackFired := false
+	u.OnRollback(func(err error) {
+		rollbackFired = true
+	})
+
+	failOnUnexpectedErr(u.RegisterDirty(&VersionedEntityStub{id: "1", version: 1}), t)
+
+	err := u.Commit(context.Background())
+	if err == nil {
+		t.Fatal("should return the conflict error")
+	}
+
+	if rollbackFired {
+		t.Error("should not fire OnRollback hooks for a conflict-only outcome")
+	}
+}
+
+func TestOnInsertOnUpdateOnDeleteFireForDispatchedEntities(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	var inserted, updated, deleted Entity
+	u.OnInsert(&EntityStub{}, func(e Entity) { inserted = e })
+	u.OnUpdate(&EntityStub{}, func(e Entity) { updated = e })
+	u.OnDelete(&EntityStub{}, func(e Entity) { deleted = e })
+
+	newEntity := &EntityStub{id: "1"}
+	dirtyEntity := &EntityStub{id: "2"}
+	deletedEntity := &EntityStub{id: "3"}
+
+	failOnUnexpectedErr(u.RegisterNew(newEntity), t)
+	failOnUnexpectedErr(u.RegisterDirty(dirtyEntity), t)
+	failOnUnexpectedErr(u.RegisterDeleted(deletedEntity), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if inserted != newEntity {
+		t.Error("should fire OnInsert for the inserted entity")
+	}
+
+	if updated != dirtyEntity {
+		t.Error("should fire OnUpdate for the updated entity")
+	}
+
+	if deleted != deletedEntity {
+		t.Error("should fire OnDelete for the deleted entity")
+	}
+}
+
+func TestOnInsertFiresForEachBatchDispatchedEntity(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &BatchMapperStub{})
+
+	var insertedIDs []string
+	u.OnInsert(&EntityStub{}, func(e Entity) {
+		insertedIDs = append(insertedIDs, e.GetID())
+	})
+
+	failOnUnexpectedErr(u.RegisterNewCollection([]Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(insertedIDs) != 2 {
+		t.Errorf("should fire OnInsert once per entity in the batch, got %+v", insertedIDs)
+	}
+}
@@ -0,0 +1,224 @@
+package datamapper
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Cloner lets an Entity take over how it is snapshotted by the IdentityMap,
+// for types where a reflection-based deep copy would be wasteful or unsafe
+type Cloner interface {
+	// Clone returns a deep copy of the entity, independent of the original
+	Clone() Entity
+}
+
+// ChangeSet maps the name of each exported field that changed since an
+// entity was tracked to its current value, so a mapper can issue a partial
+// update instead of writing every column
+type ChangeSet map[string]interface{}
+
+// identityMapEntry pairs a tracked entity with the snapshot taken of it at
+// Track time
+type identityMapEntry struct {
+	entity   Entity
+	snapshot Entity
+}
+
+// IdentityMap snapshots entities as they are loaded so the UnitOfWork can
+// later detect which ones actually changed, without callers having to call
+// RegisterDirty themselves
+type IdentityMap struct {
+	entries map[string]identityMapEntry
+}
+
+// newIdentityMap creates an empty IdentityMap
+func newIdentityMap() *IdentityMap {
+	return &IdentityMap{
+		entries: make(map[string]identityMapEntry),
+	}
+}
+
+// identityKey disambiguates entities of different types that happen to
+// share an ID
+func identityKey(entity Entity) string {
+	return reflect.TypeOf(entity).String() + ":" + entity.GetID()
+}
+
+// track stores a snapshot of entity, cloning it via Cloner if the entity
+// implements it, or via reflection otherwise
+func (im *IdentityMap) track(entity Entity) {
+	im.entries[identityKey(entity)] = identityMapEntry{
+		entity:   entity,
+		snapshot: cloneEntity(entity),
+	}
+}
+
+// detach removes entity from the IdentityMap, so it is no longer considered
+// for automatic dirty-tracking
+func (im *IdentityMap) detach(entity Entity) {
+	delete(im.entries, identityKey(entity))
+}
+
+// retrack refreshes the snapshot of an already-tracked entity, so future
+// diffs compare against its current state instead of the one it had when it
+// was first tracked. It is a no-op if entity isn't tracked, and is meant to
+// be called once a tracked entity's pending changes have been committed.
+func (im *IdentityMap) retrack(entity Entity) {
+	key := identityKey(entity)
+
+	if _, isTracked := im.entries[key]; !isTracked {
+		return
+	}
+
+	im.entries[key] = identityMapEntry{
+		entity:   entity,
+		snapshot: cloneEntity(entity),
+	}
+}
+
+// diff compares entity against its tracked snapshot and returns the set of
+// exported fields that changed, along with whether entity is tracked at all
+func (im *IdentityMap) diff(entity Entity) (ChangeSet, bool) {
+	entry, isTracked := im.entries[identityKey(entity)]
+	if !isTracked {
+		return nil, false
+	}
+
+	return diffExportedFields(entry.snapshot, entity), true
+}
+
+// changedEntities diffs every tracked entity against its snapshot and
+// returns the ones whose exported fields have changed, along with the diff
+// for each
+func (im *IdentityMap) changedEntities() map[Entity]ChangeSet {
+	changed := make(map[Entity]ChangeSet)
+
+	for _, entry := range im.entries {
+		changes := diffExportedFields(entry.snapshot, entry.entity)
+		if len(changes) > 0 {
+			changed[entry.entity] = changes
+		}
+	}
+
+	return changed
+}
+
+// cloneEntity returns a deep copy of entity, preferring the entity's own
+// Cloner implementation and falling back to a reflection-based deep copy
+func cloneEntity(entity Entity) Entity {
+	if cloner, ok := entity.(Cloner); ok {
+		return cloner.Clone()
+	}
+
+	return deepCopy(reflect.ValueOf(entity)).Interface().(Entity)
+}
+
+// deepCopy recursively copies src into a new, independent value, including
+// unexported fields, so snapshots can't be mutated through shared pointers
+func deepCopy(src reflect.Value) reflect.Value {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return src
+		}
+
+		dst := reflect.New(src.Type().Elem())
+		dst.Elem().Set(deepCopy(src.Elem()))
+
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(src.Type()).Elem()
+
+		for i := 0; i < src.NumField(); i++ {
+			srcField, ok := accessible(src.Field(i))
+			if !ok {
+				continue
+			}
+
+			dstField, ok := accessible(dst.Field(i))
+			if !ok {
+				continue
+			}
+
+			dstField.Set(deepCopy(srcField))
+		}
+
+		return dst
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+
+		for i := 0; i < src.Len(); i++ {
+			dst.Index(i).Set(deepCopy(src.Index(i)))
+		}
+
+		return dst
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+
+		dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+
+		for _, key := range src.MapKeys() {
+			dst.SetMapIndex(key, deepCopy(src.MapIndex(key)))
+		}
+
+		return dst
+	default:
+		return src
+	}
+}
+
+// accessible returns a reflect.Value that can safely be read and set even if
+// field is unexported, reaching through reflect's write protection via
+// unsafe since the snapshot is a throwaway copy that never escapes this
+// package. The second return value is false when field can't be made
+// accessible (an unexported, non-addressable value, e.g. a map element),
+// in which case the field is left as its zero value in the clone.
+func accessible(field reflect.Value) (reflect.Value, bool) {
+	if field.CanInterface() {
+		return field, true
+	}
+
+	if !field.CanAddr() {
+		return reflect.Value{}, false
+	}
+
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem(), true
+}
+
+// diffExportedFields returns the exported fields of current that differ from
+// the same fields on snapshot
+func diffExportedFields(snapshot, current Entity) ChangeSet {
+	changes := make(ChangeSet)
+
+	snapshotValue := reflect.Indirect(reflect.ValueOf(snapshot))
+	currentValue := reflect.Indirect(reflect.ValueOf(current))
+
+	if snapshotValue.Kind() != reflect.Struct || currentValue.Kind() != reflect.Struct {
+		return changes
+	}
+
+	structType := currentValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+
+		before := snapshotValue.Field(i).Interface()
+		after := currentValue.Field(i).Interface()
+
+		if !reflect.DeepEqual(before, after) {
+			changes[field.Name] = after
+		}
+	}
+
+	return changes
+}
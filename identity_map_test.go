@@ -0,0 +1,181 @@
+package datamapper
+
+import (
+	"context"
+	"testing"
+)
+
+type TrackedEntityStub struct {
+	id   string
+	Name string
+	Tags []string
+}
+
+func (m *TrackedEntityStub) GetID() string {
+	return m.id
+}
+
+type PartialMapperStub struct {
+	changes map[string]ChangeSet
+	calls   int
+}
+
+func (m *PartialMapperStub) Insert(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *PartialMapperStub) Delete(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *PartialMapperStub) Update(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *PartialMapperStub) UpdateChanges(ctx context.Context, entity Entity, changes ChangeSet) error {
+	if m.changes == nil {
+		m.changes = make(map[string]ChangeSet)
+	}
+
+	m.changes[entity.GetID()] = changes
+	m.calls++
+
+	return nil
+}
+
+func TestTrackThenNoChangeProducesEmptyDiff(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entity := &TrackedEntityStub{id: "5", Name: "original"}
+	u.Track(entity)
+
+	changes, isTracked := u.identityMap.diff(entity)
+	if !isTracked {
+		t.Fatal("entity should be tracked")
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("should have no changes when nothing was mutated, got %+v", changes)
+	}
+}
+
+func TestTrackDetectsChangedFields(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entity := &TrackedEntityStub{id: "5", Name: "original", Tags: []string{"a"}}
+	u.Track(entity)
+
+	entity.Name = "changed"
+
+	changes, isTracked := u.identityMap.diff(entity)
+	if !isTracked {
+		t.Fatal("entity should be tracked")
+	}
+
+	if changes["Name"] != "changed" {
+		t.Errorf("should report the new value of the changed field, got %+v", changes)
+	}
+
+	if _, tagsChanged := changes["Tags"]; tagsChanged {
+		t.Errorf("should not report unchanged fields, got %+v", changes)
+	}
+}
+
+func TestTrackSnapshotIsIndependentOfSharedSlice(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entity := &TrackedEntityStub{id: "5", Tags: []string{"a"}}
+	u.Track(entity)
+
+	entity.Tags[0] = "mutated"
+
+	changes, _ := u.identityMap.diff(entity)
+
+	if _, tagsChanged := changes["Tags"]; !tagsChanged {
+		t.Error("mutating a shared slice element should be detected as a change against the cloned snapshot")
+	}
+}
+
+func TestDetachStopsTracking(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entity := &TrackedEntityStub{id: "5"}
+	u.Track(entity)
+	u.Detach(entity)
+
+	_, isTracked := u.identityMap.diff(entity)
+	if isTracked {
+		t.Error("entity should no longer be tracked after Detach")
+	}
+}
+
+func TestCommitAutoPromotesChangedTrackedEntityToDirty(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &PartialMapperStub{}
+	u.RegisterMapper(&TrackedEntityStub{}, mapper)
+
+	entity := &TrackedEntityStub{id: "5", Name: "original"}
+	u.Track(entity)
+
+	entity.Name = "changed"
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	changes, wasPartiallyUpdated := mapper.changes["5"]
+	if !wasPartiallyUpdated {
+		t.Fatal("should have dispatched a partial update for the auto-promoted entity")
+	}
+
+	if changes["Name"] != "changed" {
+		t.Errorf("change set should contain the new field value, got %+v", changes)
+	}
+
+	if _, stillDirty := u.dirtyObjects["5"]; stillDirty {
+		t.Error("should clear the entity from the dirty set after a successful commit")
+	}
+}
+
+func TestCommitRefreshesSnapshotOfAutoPromotedTrackedEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &PartialMapperStub{}
+	u.RegisterMapper(&TrackedEntityStub{}, mapper)
+
+	entity := &TrackedEntityStub{id: "5", Name: "original"}
+	u.Track(entity)
+
+	entity.Name = "changed"
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	err = u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if mapper.calls != 1 {
+		t.Errorf("should only dispatch the update once, not re-dispatch the same change on the next commit, got %d calls", mapper.calls)
+	}
+
+	if _, stillDirty := u.dirtyObjects["5"]; stillDirty {
+		t.Error("should not re-promote the entity on a commit with no further mutation")
+	}
+}
+
+func TestCommitDoesNotPromoteUnchangedTrackedEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &PartialMapperStub{}
+	u.RegisterMapper(&TrackedEntityStub{}, mapper)
+
+	entity := &TrackedEntityStub{id: "5", Name: "original"}
+	u.Track(entity)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.changes) != 0 {
+		t.Errorf("should not dispatch an update for an entity with no changes, got %+v", mapper.changes)
+	}
+}
@@ -0,0 +1,36 @@
+package datamapper
+
+// EntityIterator streams a Repository's query results as values of the
+// concrete type T instead of the untyped Entity a Queryable mapper's
+// EntityCursor deals in
+type EntityIterator[T Entity] interface {
+	// Next advances the iterator, returning false once the result set is
+	// exhausted or an error has occurred
+	Next() bool
+
+	// Entity returns the entity at the iterator's current position
+	Entity() T
+
+	// Close releases any resources held by the iterator
+	Close() error
+}
+
+// typedEntityIterator adapts an untyped EntityCursor into an
+// EntityIterator[T]
+type typedEntityIterator[T Entity] struct {
+	cursor EntityCursor
+}
+
+func (it *typedEntityIterator[T]) Next() bool {
+	return it.cursor.Next()
+}
+
+func (it *typedEntityIterator[T]) Entity() T {
+	entity, _ := it.cursor.Entity().(T)
+
+	return entity
+}
+
+func (it *typedEntityIterator[T]) Close() error {
+	return it.cursor.Close()
+}
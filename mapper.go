@@ -0,0 +1,73 @@
+package datamapper
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+// DataMapper persists entities of a single type to and from a datastore. A
+// DataMapper is registered against the UnitOfWork for every Entity type that
+// needs to be committed.
+type DataMapper interface {
+	// Insert persists a newly created entity
+	Insert(ctx context.Context, entity Entity) error
+
+	// Update persists changes to an existing entity
+	Update(ctx context.Context, entity Entity) error
+
+	// Delete removes an entity from the datastore
+	Delete(ctx context.Context, entity Entity) error
+}
+
+// VersionedDataMapper is an optional extension of DataMapper for entities
+// that implement VersionedEntity. When a mapper implements it, Commit calls
+// UpdateVersioned instead of Update for dirty entities that have a known
+// expected version, so the mapper can enforce optimistic concurrency and
+// return a ConflictError when the stored version has moved on
+type VersionedDataMapper interface {
+	DataMapper
+
+	// UpdateVersioned persists changes to entity only if expectedVersion
+	// still matches the version stored in the datastore, returning a
+	// ConflictError otherwise
+	UpdateVersioned(ctx context.Context, entity Entity, expectedVersion int64) error
+}
+
+// PartialDataMapper is an optional extension of DataMapper for mappers that
+// can write only the fields that changed. When a dirty entity was
+// auto-promoted by the identity map's change tracking and its mapper
+// implements PartialDataMapper, Commit calls UpdateChanges with the computed
+// ChangeSet instead of Update
+type PartialDataMapper interface {
+	DataMapper
+
+	// UpdateChanges persists only the fields present in changes
+	UpdateChanges(ctx context.Context, entity Entity, changes ChangeSet) error
+}
+
+// RegisterMapper associates a DataMapper with the concrete type of sample, so
+// that any Entity of that same type registered with the UnitOfWork is
+// dispatched to m at commit time
+func (unit *UnitOfWork) RegisterMapper(sample Entity, m DataMapper) {
+	if unit.mappers == nil {
+		unit.mappers = make(map[reflect.Type]DataMapper)
+	}
+
+	unit.mappers[reflect.TypeOf(sample)] = m
+}
+
+// mapperFor returns the DataMapper registered for entity's concrete type, or
+// an error if no mapper has been registered for it
+func (unit *UnitOfWork) mapperFor(entity Entity) (DataMapper, error) {
+	m, ok := unit.mappers[reflect.TypeOf(entity)]
+	if !ok {
+		return nil, errors.Errorf(
+			"no DataMapper registered for entity type %T",
+			entity,
+		)
+	}
+
+	return m, nil
+}
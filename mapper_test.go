@@ -0,0 +1,70 @@
+package datamapper
+
+import (
+	"context"
+	"testing"
+)
+
+type MapperStub struct {
+	inserted []Entity
+	updated  []Entity
+	deleted  []Entity
+	err      error
+}
+
+func (m *MapperStub) Insert(ctx context.Context, entity Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.inserted = append(m.inserted, entity)
+
+	return nil
+}
+
+func (m *MapperStub) Update(ctx context.Context, entity Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.updated = append(m.updated, entity)
+
+	return nil
+}
+
+func (m *MapperStub) Delete(ctx context.Context, entity Entity) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.deleted = append(m.deleted, entity)
+
+	return nil
+}
+
+func TestRegisterMapper(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &MapperStub{}
+
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	found, err := u.mapperFor(&EntityStub{id: "5"})
+	failOnUnexpectedErr(err, t)
+
+	if found != mapper {
+		t.Errorf("should return the mapper registered for this entity type")
+	}
+}
+
+func TestMapperForFailsWhenUnregistered(t *testing.T) {
+	u := NewUnitOfWork()
+
+	_, err := u.mapperFor(&EntityStub{id: "5"})
+
+	if err == nil {
+		t.Error("should return an error when no mapper is registered for the entity type")
+	}
+
+	errShouldContainStr(err, "no DataMapper registered", t)
+}
@@ -0,0 +1,74 @@
+package datamapper
+
+// DomainEvent describes something that happened to an entity, worth
+// recording for other parts of the system to react to later
+type DomainEvent struct {
+	Name    string
+	Payload interface{}
+}
+
+// EventSource is implemented by entities that accumulate DomainEvents as
+// they're mutated. OutboxCollector pulls these events at commit time and
+// registers them as new OutboxEvent entities in the same UnitOfWork, so they
+// are persisted atomically alongside the entity that raised them.
+type EventSource interface {
+	// PullEvents returns every DomainEvent raised since the last pull,
+	// clearing the entity's internal buffer
+	PullEvents() []DomainEvent
+}
+
+// OutboxEvent is the persisted form of a DomainEvent, ready to be written to
+// an outbox table and later relayed to a message broker
+type OutboxEvent struct {
+	ID      string
+	Name    string
+	Payload interface{}
+}
+
+// GetID returns the outbox event's ID
+func (e *OutboxEvent) GetID() string {
+	return e.ID
+}
+
+// OutboxCollector implements the transactional outbox pattern as an
+// OnBeforeCommit hook: it pulls every pending DomainEvent off each
+// registered EventSource and registers it as a new OutboxEvent, so the
+// events are written in the same Commit call as the entities that raised
+// them.
+type OutboxCollector struct {
+	idFunc func(DomainEvent) string
+}
+
+// NewOutboxCollector creates an OutboxCollector that assigns each collected
+// DomainEvent an ID using idFunc
+func NewOutboxCollector(idFunc func(DomainEvent) string) *OutboxCollector {
+	return &OutboxCollector{idFunc: idFunc}
+}
+
+// Collect pulls pending events from every EventSource registered as new,
+// dirty, or deleted on unit, and registers each one as a new OutboxEvent.
+// It is meant to be registered with UnitOfWork.OnBeforeCommit.
+func (c *OutboxCollector) Collect(unit *UnitOfWork) error {
+	for _, registry := range []map[string]Entity{unit.newObjects, unit.dirtyObjects, unit.deletedObjects} {
+		for _, entity := range registry {
+			source, ok := entity.(EventSource)
+			if !ok {
+				continue
+			}
+
+			for _, event := range source.PullEvents() {
+				outboxEvent := &OutboxEvent{
+					ID:      c.idFunc(event),
+					Name:    event.Name,
+					Payload: event.Payload,
+				}
+
+				if err := unit.RegisterNew(outboxEvent); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package datamapper
+
+import (
+	"context"
+	"testing"
+)
+
+type EventSourceEntityStub struct {
+	id     string
+	events []DomainEvent
+}
+
+func (m *EventSourceEntityStub) GetID() string {
+	return m.id
+}
+
+func (m *EventSourceEntityStub) PullEvents() []DomainEvent {
+	events := m.events
+	m.events = nil
+
+	return events
+}
+
+func TestOutboxCollectorRegistersPulledEventsAsNewEntities(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EventSourceEntityStub{}, &MapperStub{})
+
+	outboxMapper := &MapperStub{}
+	u.RegisterMapper(&OutboxEvent{}, outboxMapper)
+
+	collector := NewOutboxCollector(func(event DomainEvent) string {
+		return "outbox-" + event.Name
+	})
+	u.OnBeforeCommit(collector.Collect)
+
+	entity := &EventSourceEntityStub{
+		id: "1",
+		events: []DomainEvent{
+			{Name: "created", Payload: "1"},
+		},
+	}
+
+	failOnUnexpectedErr(u.RegisterNew(entity), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(outboxMapper.inserted) != 1 {
+		t.Fatalf("should insert the collected outbox event, got %+v", outboxMapper.inserted)
+	}
+
+	outboxEvent, ok := outboxMapper.inserted[0].(*OutboxEvent)
+	if !ok {
+		t.Fatalf("inserted entity should be an *OutboxEvent, got %T", outboxMapper.inserted[0])
+	}
+
+	if outboxEvent.ID != "outbox-created" || outboxEvent.Name != "created" || outboxEvent.Payload != "1" {
+		t.Errorf("outbox event should carry the collected domain event's data, got %+v", outboxEvent)
+	}
+}
+
+type TrackedEventSourceEntityStub struct {
+	id     string
+	Name   string
+	events []DomainEvent
+}
+
+func (m *TrackedEventSourceEntityStub) GetID() string {
+	return m.id
+}
+
+func (m *TrackedEventSourceEntityStub) PullEvents() []DomainEvent {
+	events := m.events
+	m.events = nil
+
+	return events
+}
+
+func TestOutboxCollectorSeesEntityAutoPromotedFromTracking(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&TrackedEventSourceEntityStub{}, &MapperStub{})
+
+	outboxMapper := &MapperStub{}
+	u.RegisterMapper(&OutboxEvent{}, outboxMapper)
+
+	collector := NewOutboxCollector(func(event DomainEvent) string {
+		return "outbox-" + event.Name
+	})
+	u.OnBeforeCommit(collector.Collect)
+
+	entity := &TrackedEventSourceEntityStub{id: "1", Name: "original"}
+	u.Track(entity)
+
+	entity.Name = "renamed"
+	entity.events = append(entity.events, DomainEvent{Name: "renamed", Payload: "1"})
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(outboxMapper.inserted) != 1 {
+		t.Fatalf("should collect the event raised by an entity auto-promoted from tracking alone, got %+v", outboxMapper.inserted)
+	}
+}
+
+func TestOutboxCollectorIgnoresEntitiesThatAreNotEventSources(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	collector := NewOutboxCollector(func(event DomainEvent) string {
+		return event.Name
+	})
+	u.OnBeforeCommit(collector.Collect)
+
+	failOnUnexpectedErr(u.RegisterNew(&EntityStub{id: "1"}), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+}
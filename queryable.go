@@ -0,0 +1,37 @@
+package datamapper
+
+import "context"
+
+// Specification describes the read criteria passed to a Queryable mapper's
+// Query method. The package has no opinion on its shape; a mapper backed by
+// SQL might expect a where-clause builder, one backed by an HTTP API might
+// expect a filter struct.
+type Specification any
+
+// EntityCursor streams query results one Entity at a time instead of
+// loading the full result set into memory
+type EntityCursor interface {
+	// Next advances the cursor, returning false once the result set is
+	// exhausted or an error has occurred
+	Next() bool
+
+	// Entity returns the entity at the cursor's current position
+	Entity() Entity
+
+	// Close releases any resources held by the cursor
+	Close() error
+}
+
+// Queryable is an optional extension of DataMapper for mappers that can
+// also read entities back out of the datastore, which Repository needs for
+// Find and FindAll
+type Queryable interface {
+	DataMapper
+
+	// FindByID loads a single entity by ID
+	FindByID(ctx context.Context, id string) (Entity, error)
+
+	// Query runs spec against the datastore and returns a cursor over the
+	// matching entities
+	Query(ctx context.Context, spec Specification) (EntityCursor, error)
+}
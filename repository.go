@@ -0,0 +1,86 @@
+package datamapper
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+// Repository is a generic facade over a UnitOfWork for a single Entity
+// type T, so calling code stops juggling interface{}-typed entities and
+// gets compile-time safety per aggregate root, while still sharing the
+// underlying UnitOfWork (and so the same business transaction) with any
+// other Repository or TypedUnitOfWork constructed over it.
+type Repository[T Entity] struct {
+	*TypedUnitOfWork[T]
+
+	sample T
+}
+
+// NewRepository creates a Repository for T, backed by uow. sample is a
+// zero-value instance of T, used only to look up the DataMapper registered
+// for T's concrete type.
+func NewRepository[T Entity](uow *UnitOfWork, sample T) *Repository[T] {
+	return &Repository[T]{
+		TypedUnitOfWork: NewTypedUnitOfWork[T](uow),
+		sample:          sample,
+	}
+}
+
+// queryableMapper returns the Queryable mapper registered for T, or an
+// error if none is registered or the registered mapper can't read
+func (r *Repository[T]) queryableMapper() (Queryable, error) {
+	m, err := r.Unwrap().mapperFor(r.sample)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	qm, ok := m.(Queryable)
+	if !ok {
+		return nil, errors.Errorf(
+			"DataMapper registered for type %T does not implement Queryable",
+			r.sample,
+		)
+	}
+
+	return qm, nil
+}
+
+// Find loads the entity with the given ID
+func (r *Repository[T]) Find(ctx context.Context, id string) (T, error) {
+	var zero T
+
+	qm, err := r.queryableMapper()
+	if err != nil {
+		return zero, errors.Trace(err)
+	}
+
+	entity, err := qm.FindByID(ctx, id)
+	if err != nil {
+		return zero, errors.Annotatef(err, "failed to find entity with ID \"%s\"", id)
+	}
+
+	typed, ok := entity.(T)
+	if !ok {
+		return zero, errors.Errorf("mapper returned an entity of an unexpected type: %T", entity)
+	}
+
+	return typed, nil
+}
+
+// FindAll runs spec against T's mapper and returns an iterator over the
+// matching entities, so large result sets can be streamed instead of
+// loaded fully into memory
+func (r *Repository[T]) FindAll(ctx context.Context, spec Specification) (EntityIterator[T], error) {
+	qm, err := r.queryableMapper()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	cursor, err := qm.Query(ctx, spec)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &typedEntityIterator[T]{cursor: cursor}, nil
+}
@@ -0,0 +1,132 @@
+package datamapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+type QueryableMapperStub struct {
+	byID  map[string]Entity
+	all   []Entity
+	store map[string]Entity
+}
+
+func (m *QueryableMapperStub) Insert(ctx context.Context, entity Entity) error {
+	if m.store == nil {
+		m.store = make(map[string]Entity)
+	}
+
+	m.store[entity.GetID()] = entity
+
+	return nil
+}
+
+func (m *QueryableMapperStub) Update(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *QueryableMapperStub) Delete(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *QueryableMapperStub) FindByID(ctx context.Context, id string) (Entity, error) {
+	entity, ok := m.byID[id]
+	if !ok {
+		return nil, errors.Errorf("no entity found with ID \"%s\"", id)
+	}
+
+	return entity, nil
+}
+
+func (m *QueryableMapperStub) Query(ctx context.Context, spec Specification) (EntityCursor, error) {
+	return &sliceCursor{entities: m.all, pos: -1}, nil
+}
+
+type sliceCursor struct {
+	entities []Entity
+	pos      int
+}
+
+func (c *sliceCursor) Next() bool {
+	c.pos++
+
+	return c.pos < len(c.entities)
+}
+
+func (c *sliceCursor) Entity() Entity {
+	return c.entities[c.pos]
+}
+
+func (c *sliceCursor) Close() error {
+	return nil
+}
+
+func TestRepositoryFindReturnsTypedEntity(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entity := &EntityStub{id: "5"}
+	mapper := &QueryableMapperStub{byID: map[string]Entity{"5": entity}}
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	repo := NewRepository[*EntityStub](u, &EntityStub{})
+
+	found, err := repo.Find(context.Background(), "5")
+	failOnUnexpectedErr(err, t)
+
+	if found != entity {
+		t.Errorf("should return the entity found by the mapper, got %+v", found)
+	}
+}
+
+func TestRepositoryFindFailsWithoutQueryableMapper(t *testing.T) {
+	u := NewUnitOfWork()
+	u.RegisterMapper(&EntityStub{}, &MapperStub{})
+
+	repo := NewRepository[*EntityStub](u, &EntityStub{})
+
+	_, err := repo.Find(context.Background(), "5")
+	if err == nil {
+		t.Error("should return an error when the registered mapper doesn't implement Queryable")
+	}
+
+	errShouldContainStr(err, "does not implement Queryable", t)
+}
+
+func TestRepositoryFindAllStreamsEntities(t *testing.T) {
+	u := NewUnitOfWork()
+
+	entities := []Entity{&EntityStub{id: "1"}, &EntityStub{id: "2"}}
+	mapper := &QueryableMapperStub{all: entities}
+	u.RegisterMapper(&EntityStub{}, mapper)
+
+	repo := NewRepository[*EntityStub](u, &EntityStub{})
+
+	it, err := repo.FindAll(context.Background(), nil)
+	failOnUnexpectedErr(err, t)
+
+	var found []string
+	for it.Next() {
+		found = append(found, it.Entity().GetID())
+	}
+	failOnUnexpectedErr(it.Close(), t)
+
+	if len(found) != 2 || found[0] != "1" || found[1] != "2" {
+		t.Errorf("should iterate every entity returned by the query, got %+v", found)
+	}
+}
+
+func TestRepositoryRegisterNewDelegatesToUnitOfWork(t *testing.T) {
+	u := NewUnitOfWork()
+
+	repo := NewRepository[*EntityStub](u, &EntityStub{})
+
+	entity := &EntityStub{id: "5"}
+	failOnUnexpectedErr(repo.RegisterNew(entity), t)
+
+	foundEntity, isRegisteredNew := u.newObjects["5"]
+	if !isRegisteredNew || foundEntity != entity {
+		t.Error("should register the entity as new on the shared UnitOfWork")
+	}
+}
@@ -0,0 +1,51 @@
+package datamapper
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/juju/errors"
+)
+
+// SQLTxRunner adapts a *sql.DB into a TxRunner, so a UnitOfWork can drive a
+// database/sql transaction via CommitTx
+type SQLTxRunner struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// NewSQLTxRunner creates a SQLTxRunner bound to db. The underlying
+// transaction isn't opened until Begin is called
+func NewSQLTxRunner(db *sql.DB) *SQLTxRunner {
+	return &SQLTxRunner{db: db}
+}
+
+// Begin opens the underlying *sql.Tx
+func (r *SQLTxRunner) Begin(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	r.tx = tx
+
+	return nil
+}
+
+// Commit commits the underlying *sql.Tx
+func (r *SQLTxRunner) Commit() error {
+	if r.tx == nil {
+		return errors.New("transaction has not begun")
+	}
+
+	return errors.Trace(r.tx.Commit())
+}
+
+// Rollback rolls back the underlying *sql.Tx
+func (r *SQLTxRunner) Rollback() error {
+	if r.tx == nil {
+		return errors.New("transaction has not begun")
+	}
+
+	return errors.Trace(r.tx.Rollback())
+}
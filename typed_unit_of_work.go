@@ -0,0 +1,58 @@
+package datamapper
+
+import "context"
+
+// TypedUnitOfWork is a generic, type-safe view onto a single entity type's
+// slice of a UnitOfWork's business transaction. Several TypedUnitOfWork
+// values backed by the same UnitOfWork can be used side by side so a single
+// Commit still spans every entity type involved.
+type TypedUnitOfWork[T Entity] struct {
+	uow *UnitOfWork
+}
+
+// NewTypedUnitOfWork creates a TypedUnitOfWork for T, backed by uow
+func NewTypedUnitOfWork[T Entity](uow *UnitOfWork) *TypedUnitOfWork[T] {
+	return &TypedUnitOfWork[T]{uow: uow}
+}
+
+// Unwrap returns the untyped UnitOfWork backing this TypedUnitOfWork, for
+// callers that need to mix in other entity types or call Commit directly
+func (t *TypedUnitOfWork[T]) Unwrap() *UnitOfWork {
+	return t.uow
+}
+
+// RegisterNew registers entity as being new
+func (t *TypedUnitOfWork[T]) RegisterNew(entity T) error {
+	return t.uow.RegisterNew(entity)
+}
+
+// RegisterDirty registers entity as being dirty
+func (t *TypedUnitOfWork[T]) RegisterDirty(entity T) error {
+	return t.uow.RegisterDirty(entity)
+}
+
+// RegisterDeleted registers entity as being deleted
+func (t *TypedUnitOfWork[T]) RegisterDeleted(entity T) error {
+	return t.uow.RegisterDeleted(entity)
+}
+
+// Track registers entity with the identity map so changes to its exported
+// fields are automatically promoted to dirty at commit time
+func (t *TypedUnitOfWork[T]) Track(entity T) {
+	t.uow.Track(entity)
+}
+
+// Detach stops tracking entity
+func (t *TypedUnitOfWork[T]) Detach(entity T) {
+	t.uow.Detach(entity)
+}
+
+// Commit delegates to the underlying UnitOfWork's Commit
+func (t *TypedUnitOfWork[T]) Commit(ctx context.Context) error {
+	return t.uow.Commit(ctx)
+}
+
+// Rollback delegates to the underlying UnitOfWork's Rollback
+func (t *TypedUnitOfWork[T]) Rollback() {
+	t.uow.Rollback()
+}
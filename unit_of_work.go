@@ -2,6 +2,8 @@ package datamapper
 
 import (
 	"fmt"
+	"reflect"
+
 	"github.com/juju/errors"
 )
 
@@ -22,6 +24,43 @@ type UnitOfWork struct {
 
 	// A map of objects to be deleted from the datastore
 	deletedObjects map[string]Entity
+
+	// A map of registered DataMapper implementations, keyed by the concrete
+	// Entity type each one knows how to persist
+	mappers map[reflect.Type]DataMapper
+
+	// A map of the version each VersionedEntity was known to have when it
+	// was registered as dirty or clean, keyed by entity ID
+	loadedVersions map[string]int64
+
+	// Snapshots of tracked entities, used to automatically detect dirty
+	// entities at commit time instead of requiring callers to call
+	// RegisterDirty themselves
+	identityMap *IdentityMap
+
+	// The ChangeSet computed by the identity map for each entity it
+	// auto-promoted to dirty, keyed by entity ID, so mappers can apply a
+	// partial update instead of writing every field
+	changeSets map[string]ChangeSet
+
+	// Hooks run before Commit dispatches anything to a mapper
+	beforeCommitHooks []func(*UnitOfWork) error
+
+	// Hooks run once Commit has dispatched every entity, even if some of
+	// them hit a ConflictError
+	afterCommitHooks []func(CommitResult)
+
+	// Hooks run whenever Commit aborts or Rollback is called
+	rollbackHooks []func(error)
+
+	// Hooks run after an entity of a given concrete type is inserted
+	insertHooks map[reflect.Type][]func(Entity)
+
+	// Hooks run after an entity of a given concrete type is updated
+	updateHooks map[reflect.Type][]func(Entity)
+
+	// Hooks run after an entity of a given concrete type is deleted
+	deleteHooks map[reflect.Type][]func(Entity)
 }
 
 // NewUnitOfWork creates a new instance of UnitOfWork
@@ -30,9 +69,28 @@ func NewUnitOfWork() *UnitOfWork {
 		newObjects:     make(map[string]Entity),
 		dirtyObjects:   make(map[string]Entity),
 		deletedObjects: make(map[string]Entity),
+		loadedVersions: make(map[string]int64),
+		identityMap:    newIdentityMap(),
+		changeSets:     make(map[string]ChangeSet),
+		insertHooks:    make(map[reflect.Type][]func(Entity)),
+		updateHooks:    make(map[reflect.Type][]func(Entity)),
+		deleteHooks:    make(map[reflect.Type][]func(Entity)),
 	}
 }
 
+// Track registers entity with the identity map so that any change to its
+// exported fields is automatically detected and promoted to the dirty set
+// at commit time
+func (unit *UnitOfWork) Track(entity Entity) {
+	unit.identityMap.track(entity)
+}
+
+// Detach stops tracking entity, so subsequent changes to it are no longer
+// automatically promoted to the dirty set
+func (unit *UnitOfWork) Detach(entity Entity) {
+	unit.identityMap.detach(entity)
+}
+
 // assertEntityHasAnID returns an error if an entity has no ID
 func (unit *UnitOfWork) assertEntityHasID(entity Entity) error {
 	if entity.GetID() == "" {
@@ -117,6 +175,10 @@ func (unit *UnitOfWork) RegisterDirty(entity Entity) error {
 		return err
 	}
 
+	if versioned, ok := entity.(VersionedEntity); ok {
+		unit.loadedVersions[entity.GetID()] = versioned.GetVersion()
+	}
+
 	_, entityIsAlreadyDirty := unit.newObjects[entity.GetID()]
 	if !entityIsAlreadyDirty {
 		unit.dirtyObjects[entity.GetID()] = entity
@@ -125,6 +187,132 @@ func (unit *UnitOfWork) RegisterDirty(entity Entity) error {
 	return nil
 }
 
+// RegisterNewCollection registers every entity in entities as being new.
+// The whole slice is validated before anything is registered, so a single
+// invalid entity leaves the UnitOfWork untouched instead of registering
+// everything ahead of the failure
+func (unit *UnitOfWork) RegisterNewCollection(entities []Entity) error {
+	seen := make(map[string]bool, len(entities))
+
+	for _, entity := range entities {
+		if err := unit.assertEntityHasID(entity); err != nil {
+			return err
+		}
+
+		if err := unit.assertEntityNotRegisteredAs(entity, "dirty"); err != nil {
+			return err
+		}
+
+		if err := unit.assertEntityNotRegisteredAs(entity, "deleted"); err != nil {
+			return err
+		}
+
+		if err := unit.assertEntityNotRegisteredAs(entity, "new"); err != nil {
+			return err
+		}
+
+		if seen[entity.GetID()] {
+			return errors.Errorf(
+				"Registering entity failed: entity with ID \"%s\" is registered more than once in the same collection",
+				entity.GetID(),
+			)
+		}
+
+		seen[entity.GetID()] = true
+	}
+
+	for _, entity := range entities {
+		unit.newObjects[entity.GetID()] = entity
+	}
+
+	return nil
+}
+
+// RegisterDirtyCollection registers every entity in entities as being
+// dirty. The whole slice is validated before anything is registered, so a
+// single invalid entity leaves the UnitOfWork untouched instead of
+// registering everything ahead of the failure
+func (unit *UnitOfWork) RegisterDirtyCollection(entities []Entity) error {
+	seen := make(map[string]bool, len(entities))
+
+	for _, entity := range entities {
+		if err := unit.assertEntityHasID(entity); err != nil {
+			return err
+		}
+
+		if err := unit.assertEntityNotRegisteredAs(entity, "deleted"); err != nil {
+			return err
+		}
+
+		if seen[entity.GetID()] {
+			return errors.Errorf(
+				"Registering entity failed: entity with ID \"%s\" is registered more than once in the same collection",
+				entity.GetID(),
+			)
+		}
+
+		seen[entity.GetID()] = true
+	}
+
+	for _, entity := range entities {
+		if versioned, ok := entity.(VersionedEntity); ok {
+			unit.loadedVersions[entity.GetID()] = versioned.GetVersion()
+		}
+
+		_, entityIsAlreadyNew := unit.newObjects[entity.GetID()]
+		if !entityIsAlreadyNew {
+			unit.dirtyObjects[entity.GetID()] = entity
+		}
+	}
+
+	return nil
+}
+
+// RegisterDeletedCollection registers every entity in entities as being
+// deleted. The whole slice is validated before anything is registered, so a
+// single invalid entity leaves the UnitOfWork untouched instead of
+// registering everything ahead of the failure
+func (unit *UnitOfWork) RegisterDeletedCollection(entities []Entity) error {
+	for _, entity := range entities {
+		if err := unit.assertEntityHasID(entity); err != nil {
+			return err
+		}
+	}
+
+	for _, entity := range entities {
+		id := entity.GetID()
+
+		_, entityIsAlreadyNew := unit.newObjects[id]
+		if entityIsAlreadyNew {
+			delete(unit.newObjects, id)
+
+			continue
+		}
+
+		delete(unit.dirtyObjects, id)
+
+		_, entityIsAlreadyDeleted := unit.deletedObjects[id]
+		if !entityIsAlreadyDeleted {
+			unit.deletedObjects[id] = entity
+		}
+	}
+
+	return nil
+}
+
+// RegisterClean seeds the UnitOfWork with an entity's known version without
+// marking it dirty, so freshly-loaded entities can be used for optimistic
+// concurrency checks even before anything about them has changed
+func (unit *UnitOfWork) RegisterClean(entity Entity, version int64) error {
+	if err := unit.assertEntityHasID(entity); err != nil {
+		return err
+	}
+
+	unit.loadedVersions[entity.GetID()] = version
+
+	return nil
+}
+
 // RegisterDeleted registers a domain entity as being deleted
 func (unit *UnitOfWork) RegisterDeleted(entity Entity) error {
 	// Entity must have an ID
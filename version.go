@@ -0,0 +1,42 @@
+package datamapper
+
+import "fmt"
+
+// VersionedEntity is implemented by entities that participate in optimistic
+// concurrency control. GetVersion returns the entity's current resource
+// version; SetVersion updates it once a write has been persisted
+type VersionedEntity interface {
+	Entity
+
+	// GetVersion returns the entity's resource version
+	GetVersion() int64
+
+	// SetVersion sets the entity's resource version
+	SetVersion(version int64)
+}
+
+// ConflictError is returned by a DataMapper when the version of an entity it
+// was asked to persist no longer matches the version stored in the
+// datastore, meaning some other writer committed a change in between. It is
+// exported so callers can detect it with errors.As and reload the entity
+// before retrying
+type ConflictError struct {
+	// EntityID is the ID of the entity that failed to commit
+	EntityID string
+
+	// ExpectedVersion is the version the UnitOfWork believed was current
+	ExpectedVersion int64
+
+	// ActualVersion is the version actually stored in the datastore
+	ActualVersion int64
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		"conflict persisting entity with ID \"%s\": expected version %d, actual version %d",
+		e.EntityID,
+		e.ExpectedVersion,
+		e.ActualVersion,
+	)
+}
@@ -0,0 +1,149 @@
+package datamapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type VersionedEntityStub struct {
+	id      string
+	version int64
+}
+
+func (m *VersionedEntityStub) GetID() string {
+	return m.id
+}
+
+func (m *VersionedEntityStub) GetVersion() int64 {
+	return m.version
+}
+
+func (m *VersionedEntityStub) SetVersion(version int64) {
+	m.version = version
+}
+
+type VersionedMapperStub struct {
+	conflictFor map[string]int64
+	updated     []Entity
+}
+
+func (m *VersionedMapperStub) Insert(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *VersionedMapperStub) Delete(ctx context.Context, entity Entity) error {
+	return nil
+}
+
+func (m *VersionedMapperStub) Update(ctx context.Context, entity Entity) error {
+	m.updated = append(m.updated, entity)
+
+	return nil
+}
+
+func (m *VersionedMapperStub) UpdateVersioned(ctx context.Context, entity Entity, expectedVersion int64) error {
+	if actual, isConflicted := m.conflictFor[entity.GetID()]; isConflicted {
+		return &ConflictError{
+			EntityID:        entity.GetID(),
+			ExpectedVersion: expectedVersion,
+			ActualVersion:   actual,
+		}
+	}
+
+	m.updated = append(m.updated, entity)
+
+	return nil
+}
+
+func TestConflictErrorMessage(t *testing.T) {
+	err := &ConflictError{EntityID: "5", ExpectedVersion: 1, ActualVersion: 2}
+
+	errShouldContainStr(err, "entity with ID \"5\"", t)
+	errShouldContainStr(err, "expected version 1", t)
+	errShouldContainStr(err, "actual version 2", t)
+}
+
+func TestRegisterDirtyCapturesLoadedVersion(t *testing.T) {
+	m := &VersionedEntityStub{id: "5", version: 3}
+
+	u := NewUnitOfWork()
+
+	failOnUnexpectedErr(u.RegisterDirty(m), t)
+
+	version, ok := u.loadedVersions["5"]
+	if !ok || version != 3 {
+		t.Errorf("should capture the entity's version at registration time, got %d", version)
+	}
+}
+
+func TestRegisterCleanSeedsLoadedVersionWithoutMarkingDirty(t *testing.T) {
+	m := &VersionedEntityStub{id: "5", version: 7}
+
+	u := NewUnitOfWork()
+
+	failOnUnexpectedErr(u.RegisterClean(m, 7), t)
+
+	version, ok := u.loadedVersions["5"]
+	if !ok || version != 7 {
+		t.Errorf("should seed the known version, got %d", version)
+	}
+
+	if _, isDirty := u.dirtyObjects["5"]; isDirty {
+		t.Error("should not register the entity as dirty")
+	}
+}
+
+func TestCommitReturnsConflictErrorAndLeavesEntityDirty(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &VersionedMapperStub{conflictFor: map[string]int64{"5": 4}}
+	u.RegisterMapper(&VersionedEntityStub{}, mapper)
+
+	entity := &VersionedEntityStub{id: "5", version: 3}
+	failOnUnexpectedErr(u.RegisterDirty(entity), t)
+
+	err := u.Commit(context.Background())
+
+	if err == nil {
+		t.Fatal("should return an error when a mapper reports a conflict")
+	}
+
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("should return a *ConflictError, got %T: %s", err, err)
+	}
+
+	if conflictErr.EntityID != "5" || conflictErr.ExpectedVersion != 3 || conflictErr.ActualVersion != 4 {
+		t.Errorf("conflict error should describe the mismatch, got %+v", conflictErr)
+	}
+
+	if _, isDirty := u.dirtyObjects["5"]; !isDirty {
+		t.Error("should leave the conflicted entity registered as dirty")
+	}
+}
+
+func TestCommitClearsLoadedVersionOnSuccessfulUpdate(t *testing.T) {
+	u := NewUnitOfWork()
+
+	mapper := &VersionedMapperStub{conflictFor: map[string]int64{}}
+	u.RegisterMapper(&VersionedEntityStub{}, mapper)
+
+	entity := &VersionedEntityStub{id: "5", version: 3}
+	failOnUnexpectedErr(u.RegisterDirty(entity), t)
+
+	err := u.Commit(context.Background())
+	failOnUnexpectedErr(err, t)
+
+	if len(mapper.updated) != 1 || mapper.updated[0] != entity {
+		t.Errorf("should have dispatched the versioned update, got %+v", mapper.updated)
+	}
+
+	if _, stillDirty := u.dirtyObjects["5"]; stillDirty {
+		t.Error("should clear the entity from the dirty set after a successful update")
+	}
+
+	if _, stillTracked := u.loadedVersions["5"]; stillTracked {
+		t.Error("should clear the loaded version after a successful update")
+	}
+}